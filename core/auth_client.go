@@ -0,0 +1,180 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	ttnerrors "github.com/TheThingsNetwork/ttn/core/errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+)
+
+// defaultRefreshInterval is used when the current token has no readable
+// "exp" claim, e.g. because it hasn't been fetched yet. A token with "exp"
+// but no "iat" is treated as issued now.
+const defaultRefreshInterval = time.Minute
+
+// AuthClientInterceptor keeps a Component's outgoing bearer token fresh. It
+// attaches the token to every RPC's metadata, refreshes it in the
+// background before it expires, and retries once after an Unauthenticated
+// response.
+type AuthClientInterceptor struct {
+	component *Component
+	refresh   func() (string, error)
+
+	mu    sync.RWMutex
+	token string
+
+	closed chan struct{}
+}
+
+// NewAuthClientInterceptor creates an AuthClientInterceptor for c that calls
+// refresh to fetch a new token, starting from c.Identity.Token, and starts
+// its background refresh goroutine.
+func NewAuthClientInterceptor(c *Component, refresh func() (string, error)) *AuthClientInterceptor {
+	i := &AuthClientInterceptor{
+		component: c,
+		refresh:   refresh,
+		closed:    make(chan struct{}),
+	}
+	if c.Identity != nil {
+		i.token = c.Identity.Token
+	}
+	go i.refreshLoop()
+	return i
+}
+
+// Close stops the background refresh goroutine.
+func (i *AuthClientInterceptor) Close() {
+	close(i.closed)
+}
+
+// Token returns the token currently attached to outgoing RPCs.
+func (i *AuthClientInterceptor) Token() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.token
+}
+
+func (i *AuthClientInterceptor) setToken(token string) {
+	i.mu.Lock()
+	i.token = token
+	i.mu.Unlock()
+	if i.component.Identity != nil {
+		i.component.Identity.Token = token
+	}
+}
+
+// refreshLoop refreshes the token at 80% of its lifetime, falling back to
+// defaultRefreshInterval when the lifetime can't be determined.
+func (i *AuthClientInterceptor) refreshLoop() {
+	for {
+		select {
+		case <-time.After(i.nextRefresh()):
+			i.doRefresh()
+		case <-i.closed:
+			return
+		}
+	}
+}
+
+func (i *AuthClientInterceptor) nextRefresh() time.Duration {
+	token := i.Token()
+	if token == "" {
+		return defaultRefreshInterval
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return defaultRefreshInterval
+	}
+	expAt, ok := claims["exp"].(float64)
+	if !ok {
+		return defaultRefreshInterval
+	}
+	exp := time.Unix(int64(expAt), 0)
+	iat := time.Now()
+	if iatAt, ok := claims["iat"].(float64); ok {
+		iat = time.Unix(int64(iatAt), 0)
+	}
+	lifetime := exp.Sub(iat)
+	if lifetime <= 0 {
+		return defaultRefreshInterval
+	}
+	wait := iat.Add(time.Duration(float64(lifetime) * 0.8)).Sub(time.Now())
+	if wait <= 0 {
+		return time.Second
+	}
+	return wait
+}
+
+func (i *AuthClientInterceptor) doRefresh() {
+	token, err := i.refresh()
+	if err != nil {
+		i.component.Ctx.Warnf("ttn: Failed to refresh auth token: %s", err.Error())
+		return
+	}
+	i.setToken(token)
+}
+
+// withToken attaches the current token (and this Component's ID) to ctx,
+// following the same metadata shape as Component.GetContext.
+func (i *AuthClientInterceptor) withToken(ctx context.Context) context.Context {
+	var id string
+	if i.component.Identity != nil {
+		id = i.component.Identity.Id
+	}
+	return metadata.NewContext(ctx, metadata.Pairs("token", i.Token(), "id", id))
+}
+
+// UnaryClientInterceptor attaches the current token to outgoing unary RPCs.
+// If the call fails with Unauthenticated, it forces an immediate token
+// refresh and retries exactly once.
+func (i *AuthClientInterceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(i.withToken(ctx), method, req, resp, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+		i.doRefresh()
+		return invoker(i.withToken(ctx), method, req, resp, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor attaches the current token to outgoing streaming
+// RPCs. Streams are never retried: by the time an Unauthenticated status
+// surfaces, messages may already have been sent, and replaying them would
+// not be safe.
+func (i *AuthClientInterceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(i.withToken(ctx), desc, cc, method, opts...)
+	}
+}
+
+// DialOptions returns the grpc.DialOption instances a Component should use
+// when dialing other components, together with the AuthClientInterceptor
+// backing them. It attaches and transparently refreshes this Component's
+// bearer token (fetched via refresh) and normalizes errors the same way
+// ClientDialOptions does. It replaces the old pattern of putting a static
+// token from GetContext into each call's metadata.
+//
+// NewAuthClientInterceptor starts a background refresh goroutine; callers
+// must call Close on the returned *AuthClientInterceptor once the dialed
+// connection is no longer used, or that goroutine leaks for the life of the
+// process.
+func (c *Component) DialOptions(refresh func() (string, error)) ([]grpc.DialOption, *AuthClientInterceptor) {
+	auth := NewAuthClientInterceptor(c, refresh)
+	// ttnerrors must be outermost (listed first) and auth innermost (listed
+	// last): auth's retry-on-Unauthenticated logic needs to see the raw
+	// grpc/status error out of the invoker, before ttnerrors converts it
+	// into a *ttnerrors.Error (whose status.Code is always Unknown).
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(ttnerrors.UnaryClientInterceptor(), auth.UnaryClientInterceptor())),
+		grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(ttnerrors.StreamClientInterceptor(), auth.StreamClientInterceptor())),
+	}, auth
+}
@@ -3,20 +3,23 @@ package core
 import (
 	"errors"
 	"fmt"
-	"runtime"
-	"time"
+	"strings"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 
 	"github.com/TheThingsNetwork/ttn/api"
 	pb_discovery "github.com/TheThingsNetwork/ttn/api/discovery"
+	ttnerrors "github.com/TheThingsNetwork/ttn/core/errors"
 	"github.com/TheThingsNetwork/ttn/utils/tokenkey"
 	"github.com/apex/log"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/mwitkow/go-grpc-middleware"
+	"github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/spf13/viper"
 )
 
@@ -27,18 +30,7 @@ type ComponentInterface interface {
 
 // NewComponent creates a new Component
 func NewComponent(ctx log.Interface, serviceName string, announcedAddress string) *Component {
-	go func() {
-		memstats := new(runtime.MemStats)
-		for range time.Tick(time.Minute) {
-			runtime.ReadMemStats(memstats)
-			ctx.WithFields(log.Fields{
-				"Goroutines": runtime.NumGoroutine(),
-				"Memory":     float64(memstats.Alloc) / 1000000,
-			}).Debugf("Stats")
-		}
-	}()
-
-	return &Component{
+	c := &Component{
 		Ctx: ctx,
 		Identity: &pb_discovery.Announcement{
 			Id:          viper.GetString("id"),
@@ -52,7 +44,12 @@ func NewComponent(ctx log.Interface, serviceName string, announcedAddress string
 			fmt.Sprintf("%s/key", viper.GetString("auth-server")),
 			viper.GetString("oauth2-keyfile"),
 		),
+		health: newHealth(),
 	}
+
+	serveMetrics(ctx)
+
+	return c
 }
 
 // Component contains the common attributes for all TTN components
@@ -61,6 +58,8 @@ type Component struct {
 	DiscoveryServer  string
 	Ctx              log.Interface
 	TokenKeyProvider tokenkey.Provider
+
+	health *health
 }
 
 // Announce the component to TTN discovery
@@ -73,6 +72,10 @@ func (c *Component) Announce() error {
 		return errors.New("ttn: No ID configured")
 	}
 
+	if status := c.health.overall(); status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("ttn: Not announcing to TTN discovery: a reported service is not serving")
+	}
+
 	conn, err := grpc.Dial(c.DiscoveryServer, append(api.DialOptions, grpc.WithBlock())...)
 	if err != nil {
 		return err
@@ -84,6 +87,8 @@ func (c *Component) Announce() error {
 		return fmt.Errorf("ttn: Failed to announce this component to TTN discovery: %s", err.Error())
 	}
 	c.Ctx.Info("ttn: Announced to TTN discovery")
+	announcedInfo.Reset()
+	announcedInfo.WithLabelValues(c.Identity.Id, c.Identity.NetAddress).Set(1)
 
 	return nil
 }
@@ -95,11 +100,11 @@ func (c *Component) UpdateTokenKey() error {
 	}
 
 	// Set up Auth Server Token Validation
-	tokenKey, err := c.TokenKeyProvider.Get(true)
+	keys, err := c.TokenKeyProvider.Keys(true)
 	if err != nil {
-		c.Ctx.Warnf("ttn: Failed to refresh public key for token validation: %s", err.Error())
+		c.Ctx.Warnf("ttn: Failed to refresh public key(s) for token validation: %s", err.Error())
 	} else {
-		c.Ctx.Infof("ttn: Got public key for token validation (%v)", tokenKey.Algorithm)
+		c.Ctx.Infof("ttn: Got %d public key(s) for token validation", len(keys))
 	}
 
 	return nil
@@ -112,14 +117,17 @@ func (c *Component) ValidateToken(token string) (claims map[string]interface{},
 		if c.TokenKeyProvider == nil {
 			return nil, errors.New("No token provider configured")
 		}
-		k, err := c.TokenKeyProvider.Get(false)
+		keys, err := c.TokenKeyProvider.Keys(false)
 		if err != nil {
 			return nil, err
 		}
-		if k.Algorithm != token.Header["alg"] {
-			return nil, fmt.Errorf("Expected algorithm %v but got %v", k.Algorithm, token.Header["alg"])
+		kid, _ := token.Header["kid"].(string)
+		alg := fmt.Sprintf("%v", token.Header["alg"])
+		key, err := selectTokenKey(keys, kid, alg)
+		if err != nil {
+			return nil, err
 		}
-		return []byte(k.Key), nil
+		return parseTokenKey(key)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse token: %s", err.Error())
@@ -127,7 +135,80 @@ func (c *Component) ValidateToken(token string) (claims map[string]interface{},
 	if !parsed.Valid {
 		return nil, errors.New("The token is not valid or is expired")
 	}
-	return parsed.Claims, nil
+	claims = parsed.Claims
+	if err := validateAudienceAndIssuer(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// selectTokenKey picks the key among keys that matches kid (when given) and
+// alg, so a provider serving multiple keys (e.g. a JWKSProvider) can be used
+// for token validation just like the single-key HTTPProvider. A key with no
+// KeyID (e.g. the legacy HTTPProvider's single key) is a wildcard: it
+// matches any kid, so existing deployments that don't carry a "kid" header
+// (or whose provider predates kid-based selection) keep working.
+func selectTokenKey(keys []tokenkey.Key, kid, alg string) (*tokenkey.Key, error) {
+	for _, k := range keys {
+		if k.Algorithm != alg {
+			continue
+		}
+		if kid != "" && k.KeyID != "" && k.KeyID != kid {
+			continue
+		}
+		key := k
+		return &key, nil
+	}
+	return nil, fmt.Errorf("No token key found for kid %q, alg %q", kid, alg)
+}
+
+// parseTokenKey turns a tokenkey.Key's key material into the key object
+// dgrijalva/jwt-go's verifiers expect: *rsa.PublicKey for RS*, *ecdsa.PublicKey
+// for ES*, and the raw key bytes for any other algorithm (e.g. an HS* shared
+// secret).
+func parseTokenKey(key *tokenkey.Key) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(key.Algorithm, "RS"):
+		return jwt.ParseRSAPublicKeyFromPEM([]byte(key.Key))
+	case strings.HasPrefix(key.Algorithm, "ES"):
+		return jwt.ParseECPublicKeyFromPEM([]byte(key.Key))
+	default:
+		return []byte(key.Key), nil
+	}
+}
+
+// audienceContains reports whether claims' "aud" claim contains audience.
+// RFC 7519 allows "aud" to be either a single string or an array of
+// strings, so both forms must be checked.
+func audienceContains(claims map[string]interface{}, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateAudienceAndIssuer checks the token's "aud" and "iss" claims
+// against the "token-audience" and "token-issuer" viper settings. A setting
+// that is left empty is not enforced.
+func validateAudienceAndIssuer(claims map[string]interface{}) error {
+	if audience := viper.GetString("token-audience"); audience != "" {
+		if !audienceContains(claims, audience) {
+			return fmt.Errorf("Token audience %v does not match expected %q", claims["aud"], audience)
+		}
+	}
+	if issuer := viper.GetString("token-issuer"); issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != issuer {
+			return fmt.Errorf("Token issuer %q does not match expected %q", iss, issuer)
+		}
+	}
+	return nil
 }
 
 func (c *Component) ServerOptions() []grpc.ServerOption {
@@ -175,10 +256,91 @@ func (c *Component) ServerOptions() []grpc.ServerOption {
 		return handler(srv, stream)
 	}
 
+	// authorizeUnary is NOT an authentication boundary: a call that carries
+	// no token at all is let through unchanged (see scopesFromContext), on
+	// the assumption that whatever already decides an RPC requires
+	// authentication enforces that independently. This interceptor only
+	// narrows what a call CAN do once a token is present; it never widens
+	// access and it never rejects an unauthenticated caller by itself.
+	authorizeUnary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		scopes, err := c.scopesFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if scopes == nil {
+			// Tokens without a scope claim are full-privilege (legacy behavior)
+			return handler(ctx, req)
+		}
+		for _, scope := range scopes {
+			if scope.Match(ctx, req) {
+				return handler(ctx, req)
+			}
+		}
+		return nil, ttnerrors.Wrap(ttnerrors.ErrPermissionDenied, fmt.Sprintf("ttn: Token scope does not cover %s", info.FullMethod))
+	}
+
+	// authorizeStream only checks that the token carries a valid scope; it
+	// can not match individual messages against the scope because those are
+	// not yet available at call time.
+	authorizeStream := func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, err := c.scopesFromContext(stream.Context()); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+
+	// ttnerrors must be outermost (listed first): it is the only layer that
+	// maps a Go error into the right grpc/status code, and it needs to see
+	// errors returned by every inner layer, including ones (like
+	// authorizeUnary's scope rejection) that never call the handler.
 	return []grpc.ServerOption{
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unary)),
-		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(stream)),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+			ttnerrors.UnaryServerInterceptor(c.Identity.Id),
+			grpc_prometheus.UnaryServerInterceptor,
+			grpc_opentracing.UnaryServerInterceptor(),
+			unary, authorizeUnary,
+		)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+			ttnerrors.StreamServerInterceptor(c.Identity.Id),
+			grpc_prometheus.StreamServerInterceptor,
+			grpc_opentracing.StreamServerInterceptor(),
+			stream, authorizeStream,
+		)),
+	}
+}
+
+// ClientDialOptions returns the grpc.DialOption instances components should
+// use when dialing other components, so that errors returned by those RPCs
+// come back as the sentinel errors from this package instead of raw
+// grpc/status errors.
+func (c *Component) ClientDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(ttnerrors.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(ttnerrors.StreamClientInterceptor()),
+	}
+}
+
+// scopesFromContext validates the bearer token found in ctx (if any) and
+// returns the scopes it carries. It returns a nil slice (and nil error) if
+// ctx carries no token. This is deliberately permissive: scopesFromContext
+// (and the authorizeUnary/authorizeStream interceptors built on it) only
+// restrict calls that DO present a token; a call with no token at all is
+// passed through as-is and is NOT rejected here. Handlers that require
+// authentication must enforce it themselves.
+func (c *Component) scopesFromContext(ctx context.Context) ([]Scope, error) {
+	meta, ok := metadata.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	token, ok := meta["token"]
+	if !ok || len(token) == 0 || token[0] == "" {
+		return nil, nil
+	}
+	claims, err := c.ValidateToken(token[0])
+	if err != nil {
+		return nil, ttnerrors.Wrap(ttnerrors.ErrPermissionDenied, fmt.Sprintf("ttn: Invalid token: %s", err.Error()))
 	}
+	return ScopesFromClaims(claims)
 }
 
 // GetContext returns a context for outgoing RPC requests
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/utils/tokenkey"
+	"github.com/apex/log"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// fakeTokenKeyProvider serves a fixed, pre-built set of keys, standing in
+// for a JWKSProvider/FileProvider without touching the network.
+type fakeTokenKeyProvider struct {
+	keys []tokenkey.Key
+}
+
+func (p *fakeTokenKeyProvider) Keys(refresh bool) ([]tokenkey.Key, error) {
+	return p.keys, nil
+}
+
+func pemFromPublicKey(pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestValidateTokenRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+
+	c := &Component{
+		Ctx: log.Log,
+		TokenKeyProvider: &fakeTokenKeyProvider{keys: []tokenkey.Key{
+			{Algorithm: "RS256", KeyID: "kid-rsa", Key: pemFromPublicKey(&priv.PublicKey)},
+		}},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user1"})
+	token.Header["kid"] = "kid-rsa"
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	claims, err := c.ValidateToken(signed)
+	if err != nil {
+		t.Fatalf("Expected token to validate, got error: %s", err)
+	}
+	if claims["sub"] != "user1" {
+		t.Errorf("Expected sub claim \"user1\", got %v", claims["sub"])
+	}
+}
+
+func TestValidateTokenECDSA(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %s", err)
+	}
+
+	c := &Component{
+		Ctx: log.Log,
+		TokenKeyProvider: &fakeTokenKeyProvider{keys: []tokenkey.Key{
+			{Algorithm: "ES256", KeyID: "kid-ec", Key: pemFromPublicKey(&priv.PublicKey)},
+		}},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "user2"})
+	token.Header["kid"] = "kid-ec"
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	claims, err := c.ValidateToken(signed)
+	if err != nil {
+		t.Fatalf("Expected token to validate, got error: %s", err)
+	}
+	if claims["sub"] != "user2" {
+		t.Errorf("Expected sub claim \"user2\", got %v", claims["sub"])
+	}
+}
+
+func TestAudienceContains(t *testing.T) {
+	if !audienceContains(map[string]interface{}{"aud": "component1"}, "component1") {
+		t.Error("Expected audienceContains to match a string aud claim")
+	}
+	if audienceContains(map[string]interface{}{"aud": "component1"}, "component2") {
+		t.Error("Expected audienceContains not to match a different string aud claim")
+	}
+	if !audienceContains(map[string]interface{}{"aud": []interface{}{"component1", "component2"}}, "component2") {
+		t.Error("Expected audienceContains to match an array aud claim containing the audience")
+	}
+	if audienceContains(map[string]interface{}{"aud": []interface{}{"component1"}}, "component2") {
+		t.Error("Expected audienceContains not to match an array aud claim missing the audience")
+	}
+	if audienceContains(map[string]interface{}{}, "component1") {
+		t.Error("Expected audienceContains not to match when there is no aud claim at all")
+	}
+}
+
+func TestValidateTokenUnknownKeyIDFallsBackToWildcard(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %s", err)
+	}
+
+	// A legacy single-key provider serves its key with no KeyID at all; it
+	// must still validate tokens that carry a "kid" header.
+	c := &Component{
+		Ctx: log.Log,
+		TokenKeyProvider: &fakeTokenKeyProvider{keys: []tokenkey.Key{
+			{Algorithm: "RS256", KeyID: "", Key: pemFromPublicKey(&priv.PublicKey)},
+		}},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user3"})
+	token.Header["kid"] = "whatever"
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err)
+	}
+
+	if _, err := c.ValidateToken(signed); err != nil {
+		t.Fatalf("Expected token to validate against the wildcard key, got error: %s", err)
+	}
+}
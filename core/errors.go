@@ -0,0 +1,23 @@
+package core
+
+import ttnerrors "github.com/TheThingsNetwork/ttn/core/errors"
+
+// Sentinel errors shared by all TTN components, re-exported here so that
+// handlers can write errors.Is(err, core.ErrNotFound) instead of matching on
+// error strings. See the core/errors package for their gRPC mapping.
+var (
+	ErrNotFound         = ttnerrors.ErrNotFound
+	ErrPermissionDenied = ttnerrors.ErrPermissionDenied
+	ErrAlreadyExists    = ttnerrors.ErrAlreadyExists
+	ErrInvalidArgument  = ttnerrors.ErrInvalidArgument
+	ErrInternal         = ttnerrors.ErrInternal
+)
+
+// FromGRPC converts an error returned by a gRPC client call into a
+// *ttnerrors.Error that wraps the matching sentinel error above, so that
+// errors.Is(err, core.ErrNotFound) works. For codes with no sentinel (e.g.
+// codes.Unavailable) the wrapped error is nil and only the code is kept;
+// errors that are not grpc/status errors are returned unchanged.
+func FromGRPC(err error) error {
+	return ttnerrors.FromGRPC(err)
+}
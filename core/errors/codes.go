@@ -0,0 +1,56 @@
+package errors
+
+import "google.golang.org/grpc/codes"
+
+// codeFor maps a TTN sentinel error to its gRPC status code. Errors that are
+// not one of our sentinels (or a wrapper around one) map to codes.Internal,
+// the same as ErrInternal.
+func codeFor(err error) codes.Code {
+	switch Cause(err) {
+	case ErrNotFound:
+		return codes.NotFound
+	case ErrPermissionDenied:
+		return codes.PermissionDenied
+	case ErrAlreadyExists:
+		return codes.AlreadyExists
+	case ErrInvalidArgument:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// errorFor maps a gRPC status code back to the closest TTN sentinel error.
+// codes.Internal/codes.Unknown (the codes used for ErrInternal and any other
+// non-sentinel error) map to ErrInternal. It returns nil for codes that have
+// no TTN sentinel equivalent at all, e.g. the transport-level
+// codes.Unavailable/codes.DeadlineExceeded a connection failure surfaces
+// as; FromGRPC falls back to preserving the code itself in that case.
+func errorFor(code codes.Code) error {
+	switch code {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	case codes.InvalidArgument:
+		return ErrInvalidArgument
+	case codes.Internal, codes.Unknown:
+		return ErrInternal
+	default:
+		return nil
+	}
+}
+
+// Cause unwraps err until it finds one of our sentinels (or runs out of
+// wrappers), so codeFor can classify errors built with Wrap.
+func Cause(err error) error {
+	for {
+		wrapped, ok := err.(*Error)
+		if !ok {
+			return err
+		}
+		err = wrapped.Err
+	}
+}
@@ -0,0 +1,62 @@
+// Package errors defines the typed errors shared by all TTN components and
+// the gRPC interceptors that translate between them and grpc/status codes,
+// so that callers can rely on errors.Is(err, errors.ErrNotFound) instead of
+// matching on error strings.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Sentinel errors returned by TTN components. Servers should return (or
+// wrap, see Wrap) one of these from their RPC handlers; the server
+// interceptor takes care of mapping them to the matching grpc/status code.
+var (
+	ErrNotFound         = stderrors.New("ttn: not found")
+	ErrPermissionDenied = stderrors.New("ttn: permission denied")
+	ErrAlreadyExists    = stderrors.New("ttn: already exists")
+	ErrInvalidArgument  = stderrors.New("ttn: invalid argument")
+	ErrInternal         = stderrors.New("ttn: internal error")
+)
+
+// Error wraps a TTN sentinel error with the context that travelled with it
+// over gRPC. It is what client-side callers get back from FromGRPC.
+//
+// Err is nil when the originating grpc/status code has no TTN sentinel
+// equivalent (e.g. codes.Unavailable, codes.DeadlineExceeded): Code still
+// carries the original code in that case, so callers that need to tell a
+// transient connection failure from an application error can check it
+// directly instead of getting back an indistinguishable ErrInternal.
+type Error struct {
+	Err         error
+	Code        codes.Code
+	Message     string
+	ComponentID string
+	TraceID     string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("ttn: %s: %s", e.Code, e.Message)
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Err.Error(), e.Message)
+}
+
+// Unwrap allows errors.Is(err, core.ErrNotFound) to see through the Error
+// wrapper down to the sentinel it was built from.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap attaches a descriptive message to a sentinel error without losing its
+// identity, e.g. errors.Wrap(errors.ErrNotFound, "device not found").
+func Wrap(err error, message string) error {
+	return &Error{Err: err, Message: message}
+}
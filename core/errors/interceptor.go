@@ -0,0 +1,119 @@
+package errors
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// newTraceID returns a short random identifier used to correlate a server
+// side error with the client that observed it, without having to grep logs
+// across components.
+func newTraceID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return fmt.Sprintf("%x", buf)
+}
+
+// toStatus turns a Go error returned by an RPC handler into a grpc/status
+// error, tagging it with componentID and a fresh trace ID so the original
+// error can be reconstructed client-side by FromGRPC. An error that already is
+// a grpc/status error (e.g. NotFound from the health service) keeps its code
+// and message.
+func toStatus(err error, componentID string) error {
+	if err == nil {
+		return nil
+	}
+	traceID := newTraceID()
+	code, message := codeFor(err), err.Error()
+	if orig, ok := status.FromError(err); ok {
+		code, message = orig.Code(), orig.Message()
+	}
+	st := status.New(code, message)
+	if withDetails, detailsErr := st.WithDetails(&wrappers.StringValue{
+		Value: componentID + "|" + traceID,
+	}); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// FromGRPC converts an error returned by a gRPC client call back into a TTN
+// error, so handlers can use errors.Is(err, core.ErrNotFound) instead of
+// matching on the status message. Errors that status.FromError can't turn
+// into a status at all (i.e. err is nil or isn't a grpc/status error) are
+// returned unchanged. Connection failures (codes.Unavailable,
+// codes.DeadlineExceeded, ...) ARE grpc/status errors, so they do go
+// through this conversion, but since they have no TTN sentinel equivalent,
+// the returned *Error has a nil Err and carries the original code in Code
+// instead of collapsing to ErrInternal.
+func FromGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	ttnErr := &Error{
+		Err:     errorFor(st.Code()),
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		str, ok := detail.(*wrappers.StringValue)
+		if !ok {
+			continue
+		}
+		parts := strings.SplitN(str.Value, "|", 2)
+		if len(parts) == 2 {
+			ttnErr.ComponentID, ttnErr.TraceID = parts[0], parts[1]
+		}
+	}
+	return ttnErr
+}
+
+// UnaryServerInterceptor converts errors returned by unary RPC handlers into
+// grpc/status errors carrying componentID and a trace ID.
+func UnaryServerInterceptor(componentID string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, toStatus(err, componentID)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(componentID string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toStatus(handler(srv, stream), componentID)
+	}
+}
+
+// UnaryClientInterceptor converts the grpc/status error returned by a unary
+// RPC back into a TTN error via FromGRPC.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return FromGRPC(invoker(ctx, method, req, resp, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return stream, FromGRPC(err)
+		}
+		return stream, nil
+	}
+}
@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatusFromGRPCRoundTrip(t *testing.T) {
+	original := Wrap(ErrNotFound, "device not found")
+
+	grpcErr := toStatus(original, "handler")
+	if grpcErr == nil {
+		t.Fatal("Expected toStatus to return a non-nil error")
+	}
+
+	got := FromGRPC(grpcErr)
+	ttnErr, ok := got.(*Error)
+	if !ok {
+		t.Fatalf("Expected FromGRPC to return *Error, got %T", got)
+	}
+	if ttnErr.Err != ErrNotFound {
+		t.Errorf("Expected sentinel ErrNotFound, got %v", ttnErr.Err)
+	}
+	if ttnErr.Message != "ttn: not found: device not found" {
+		t.Errorf("Unexpected message: %q", ttnErr.Message)
+	}
+	if ttnErr.ComponentID != "handler" {
+		t.Errorf("Expected ComponentID %q, got %q", "handler", ttnErr.ComponentID)
+	}
+	if ttnErr.TraceID == "" {
+		t.Error("Expected a non-empty TraceID")
+	}
+}
+
+func TestToStatusFromGRPCAllSentinels(t *testing.T) {
+	for _, sentinel := range []error{ErrNotFound, ErrPermissionDenied, ErrAlreadyExists, ErrInvalidArgument, ErrInternal} {
+		grpcErr := toStatus(sentinel, "router")
+		got := FromGRPC(grpcErr)
+		ttnErr, ok := got.(*Error)
+		if !ok {
+			t.Fatalf("Expected *Error for %v, got %T", sentinel, got)
+		}
+		if ttnErr.Err != sentinel {
+			t.Errorf("Expected %v to round-trip, got %v", sentinel, ttnErr.Err)
+		}
+	}
+}
+
+func TestFromGRPCTransportCodePreserved(t *testing.T) {
+	grpcErr := status.Error(codes.Unavailable, "connection refused")
+
+	got := FromGRPC(grpcErr)
+	ttnErr, ok := got.(*Error)
+	if !ok {
+		t.Fatalf("Expected FromGRPC to return *Error, got %T", got)
+	}
+	if ttnErr.Err != nil {
+		t.Errorf("Expected no sentinel for a transport code, got %v", ttnErr.Err)
+	}
+	if ttnErr.Code != codes.Unavailable {
+		t.Errorf("Expected Code to be preserved as Unavailable, got %v", ttnErr.Code)
+	}
+}
+
+func TestFromGRPCNonStatusError(t *testing.T) {
+	plain := Wrap(ErrInternal, "boom")
+	if got := FromGRPC(plain); got != plain {
+		t.Errorf("Expected FromGRPC to return non-status errors unchanged, got %v", got)
+	}
+}
+
+func TestFromGRPCNil(t *testing.T) {
+	if err := FromGRPC(nil); err != nil {
+		t.Errorf("Expected FromGRPC(nil) to be nil, got %v", err)
+	}
+}
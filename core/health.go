@@ -0,0 +1,103 @@
+package core
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// health implements the standard grpc.health.v1.Health service.
+// ComponentInterface implementations report readiness for the services they
+// provide (e.g. "broker" reporting whether it has loaded its routing
+// table) through Component.SetStatus; Check, when queried for the empty ""
+// service, reports SERVING only if every reported service is SERVING and
+// NOT_SERVING otherwise. Watch is not implemented.
+type health struct {
+	mu       sync.RWMutex
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+}
+
+func newHealth() *health {
+	return &health{statuses: make(map[string]healthpb.HealthCheckResponse_ServingStatus)}
+}
+
+func (h *health) set(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[service] = status
+}
+
+// overall is SERVING as long as no reported service is unhealthy. A
+// Component that never calls SetStatus (i.e. hasn't adopted per-service
+// readiness reporting) is SERVING by default, so existing components keep
+// announcing to discovery exactly as before.
+func (h *health) overall() healthpb.HealthCheckResponse_ServingStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.statuses) == 0 {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	for _, status := range h.statuses {
+		if status != healthpb.HealthCheckResponse_SERVING {
+			return healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+// Check implements healthpb.HealthServer.
+func (h *health) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service == "" {
+		return &healthpb.HealthCheckResponse{Status: h.overall()}, nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.statuses[req.Service]
+	if !ok {
+		return nil, grpc.Errorf(codes.NotFound, "ttn: Unknown service %q", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: status}, nil
+}
+
+// Watch implements healthpb.HealthServer. Streaming health updates are not
+// supported yet; clients should poll Check instead.
+func (h *health) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return grpc.Errorf(codes.Unimplemented, "ttn: Watch is not implemented, use Check")
+}
+
+// SetStatus reports the readiness of one of this Component's services, e.g.
+// SetStatus("broker", healthpb.HealthCheckResponse_SERVING) once the broker
+// has loaded its routing table. Announce refuses to publish this Component
+// to discovery once a reported service goes unhealthy.
+func (c *Component) SetStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	c.health.set(service, status)
+}
+
+// RegisterHealthServer registers the standard grpc.health.v1.Health service
+// on s, so orchestrators (k8s, consul, load balancers) can probe this
+// Component the same way they probe any other gRPC service. It is named
+// distinctly from ComponentInterface.RegisterRPC so that registering a
+// Component's own RPCs doesn't shadow it. Prefer NewServer, which calls
+// this automatically; use RegisterHealthServer directly only when a caller
+// genuinely can't go through NewServer (e.g. a test serving c.health
+// without a full Component).
+func (c *Component) RegisterHealthServer(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, c.health)
+}
+
+// NewServer builds the grpc.Server a ComponentInterface should serve on: it
+// applies c.ServerOptions, registers the grpc.health.v1.Health service, and
+// then registers i's own RPCs via i.RegisterRPC. This is the only supported
+// way to construct a Component's server, so that health (which Announce
+// depends on being reachable) is never left unregistered the way a
+// hand-rolled grpc.NewServer/RegisterHealthServer/RegisterRPC sequence could
+// forget it.
+func (c *Component) NewServer(i ComponentInterface) *grpc.Server {
+	s := grpc.NewServer(c.ServerOptions()...)
+	c.RegisterHealthServer(s)
+	i.RegisterRPC(s)
+	return s
+}
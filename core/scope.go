@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Scope determines which resources a bearer token may be used to access. It
+// is embedded in the "scope" claim of tokens minted by the auth server, and
+// checked against the request of every RPC handled by a Component.
+type Scope interface {
+	// Match reports whether the scope authorizes access to the resource
+	// targeted by req, the request message of the RPC being called.
+	Match(ctx context.Context, req interface{}) bool
+
+	// String returns the scope's canonical textual representation, as it
+	// appears in the "scope" claim of a token.
+	String() string
+}
+
+// appIDGetter is implemented by request messages that target an application.
+// The method name matches the getter protoc-gen-go generates for an "app_id"
+// field.
+type appIDGetter interface {
+	GetAppId() string
+}
+
+// devEUIGetter is implemented by request messages that target a device. The
+// method name matches the getter protoc-gen-go generates for a "dev_eui"
+// field.
+type devEUIGetter interface {
+	GetDevEui() string
+}
+
+// gatewayIDGetter is implemented by request messages that target a gateway.
+// The method name matches the getter protoc-gen-go generates for a
+// "gateway_id" field.
+type gatewayIDGetter interface {
+	GetGatewayId() string
+}
+
+// AppScope authorizes access to everything that belongs to a single
+// application.
+type AppScope struct {
+	AppID string
+}
+
+// Match implements Scope.
+func (s AppScope) Match(ctx context.Context, req interface{}) bool {
+	getter, ok := req.(appIDGetter)
+	return ok && getter.GetAppId() == s.AppID
+}
+
+// String implements Scope.
+func (s AppScope) String() string {
+	return fmt.Sprintf("app:%s", s.AppID)
+}
+
+// DeviceScope authorizes access to a single device of a single application.
+type DeviceScope struct {
+	AppID  string
+	DevEUI string
+}
+
+// Match implements Scope.
+func (s DeviceScope) Match(ctx context.Context, req interface{}) bool {
+	app, ok := req.(appIDGetter)
+	if !ok || app.GetAppId() != s.AppID {
+		return false
+	}
+	dev, ok := req.(devEUIGetter)
+	return ok && dev.GetDevEui() == s.DevEUI
+}
+
+// String implements Scope.
+func (s DeviceScope) String() string {
+	return fmt.Sprintf("device:%s:%s", s.AppID, s.DevEUI)
+}
+
+// GatewayScope authorizes access to everything that belongs to a single
+// gateway.
+type GatewayScope struct {
+	GatewayID string
+}
+
+// Match implements Scope.
+func (s GatewayScope) Match(ctx context.Context, req interface{}) bool {
+	getter, ok := req.(gatewayIDGetter)
+	return ok && getter.GetGatewayId() == s.GatewayID
+}
+
+// String implements Scope.
+func (s GatewayScope) String() string {
+	return fmt.Sprintf("gateway:%s", s.GatewayID)
+}
+
+// publicGetter is implemented by request messages that can target a
+// publicly shared resource. The method name matches the getter
+// protoc-gen-go generates for a "public" field.
+type publicGetter interface {
+	GetPublic() bool
+}
+
+// PublicShareScope authorizes access to resources that the owner has
+// explicitly marked as publicly shared, regardless of who is asking.
+type PublicShareScope struct{}
+
+// Match implements Scope. It only matches requests that explicitly flag the
+// targeted resource as public; a request with no GetPublic method, or whose
+// GetPublic returns false, is not public and must not be authorized by this
+// scope.
+func (s PublicShareScope) Match(ctx context.Context, req interface{}) bool {
+	getter, ok := req.(publicGetter)
+	return ok && getter.GetPublic()
+}
+
+// String implements Scope.
+func (s PublicShareScope) String() string {
+	return "public"
+}
+
+// ParseScope parses the textual representation of a scope, as produced by
+// Scope.String(). It is used to decode the "scope" claim of a bearer token.
+func ParseScope(s string) (Scope, error) {
+	parts := strings.Split(s, ":")
+	switch parts[0] {
+	case "app":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("ttn: Invalid app scope %q", s)
+		}
+		return AppScope{AppID: parts[1]}, nil
+	case "device":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("ttn: Invalid device scope %q", s)
+		}
+		return DeviceScope{AppID: parts[1], DevEUI: parts[2]}, nil
+	case "gateway":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("ttn: Invalid gateway scope %q", s)
+		}
+		return GatewayScope{GatewayID: parts[1]}, nil
+	case "public":
+		return PublicShareScope{}, nil
+	default:
+		return nil, fmt.Errorf("ttn: Unknown scope %q", s)
+	}
+}
+
+// ScopesFromClaims extracts and parses the "scope" claim from a set of
+// validated token claims. It returns a nil slice if the token does not
+// carry a scope claim at all, which callers should treat as a full-privilege
+// (legacy) token.
+func ScopesFromClaims(claims map[string]interface{}) ([]Scope, error) {
+	raw, ok := claims["scope"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("ttn: Invalid scope claim")
+	}
+	scopes := make([]Scope, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, errors.New("ttn: Invalid scope claim")
+		}
+		scope, err := ParseScope(str)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
@@ -0,0 +1,103 @@
+package core
+
+import "testing"
+
+// fakeDeviceRequest mimics the getters protoc-gen-go generates for a
+// message with "app_id"/"dev_eui" fields, so Match can be exercised without
+// depending on a generated request type.
+type fakeDeviceRequest struct {
+	AppID  string
+	DevEUI string
+}
+
+func (r fakeDeviceRequest) GetAppId() string  { return r.AppID }
+func (r fakeDeviceRequest) GetDevEui() string { return r.DevEUI }
+
+type fakeGatewayRequest struct {
+	GatewayID string
+}
+
+func (r fakeGatewayRequest) GetGatewayId() string { return r.GatewayID }
+
+// fakePublicRequest mimics the getter protoc-gen-go generates for a message
+// with a "public" field.
+type fakePublicRequest struct {
+	Public bool
+}
+
+func (r fakePublicRequest) GetPublic() bool { return r.Public }
+
+func TestAppScopeMatch(t *testing.T) {
+	scope := AppScope{AppID: "app1"}
+	if !scope.Match(nil, fakeDeviceRequest{AppID: "app1", DevEUI: "0102030405060708"}) {
+		t.Error("Expected AppScope to match a request for the same app")
+	}
+	if scope.Match(nil, fakeDeviceRequest{AppID: "app2"}) {
+		t.Error("Expected AppScope not to match a request for a different app")
+	}
+	if scope.Match(nil, fakeGatewayRequest{GatewayID: "gw1"}) {
+		t.Error("Expected AppScope not to match a request with no GetAppId method")
+	}
+}
+
+func TestDeviceScopeMatch(t *testing.T) {
+	scope := DeviceScope{AppID: "app1", DevEUI: "0102030405060708"}
+	if !scope.Match(nil, fakeDeviceRequest{AppID: "app1", DevEUI: "0102030405060708"}) {
+		t.Error("Expected DeviceScope to match its own device")
+	}
+	if scope.Match(nil, fakeDeviceRequest{AppID: "app1", DevEUI: "0000000000000000"}) {
+		t.Error("Expected DeviceScope not to match a different device")
+	}
+}
+
+func TestGatewayScopeMatch(t *testing.T) {
+	scope := GatewayScope{GatewayID: "gw1"}
+	if !scope.Match(nil, fakeGatewayRequest{GatewayID: "gw1"}) {
+		t.Error("Expected GatewayScope to match its own gateway")
+	}
+	if scope.Match(nil, fakeGatewayRequest{GatewayID: "gw2"}) {
+		t.Error("Expected GatewayScope not to match a different gateway")
+	}
+}
+
+func TestPublicShareScopeMatch(t *testing.T) {
+	scope := PublicShareScope{}
+	if !scope.Match(nil, fakePublicRequest{Public: true}) {
+		t.Error("Expected PublicShareScope to match a request explicitly marked public")
+	}
+	if scope.Match(nil, fakePublicRequest{Public: false}) {
+		t.Error("Expected PublicShareScope not to match a request explicitly marked non-public")
+	}
+	if scope.Match(nil, fakeDeviceRequest{AppID: "app1"}) {
+		t.Error("Expected PublicShareScope not to match a request with no GetPublic method")
+	}
+}
+
+func TestScopesFromClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"scope": []interface{}{"app:app1", "gateway:gw1"},
+	}
+	scopes, err := ScopesFromClaims(claims)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(scopes) != 2 {
+		t.Fatalf("Expected 2 scopes, got %d", len(scopes))
+	}
+	if !scopes[0].Match(nil, fakeDeviceRequest{AppID: "app1"}) {
+		t.Error("Expected first scope to match app1")
+	}
+	if !scopes[1].Match(nil, fakeGatewayRequest{GatewayID: "gw1"}) {
+		t.Error("Expected second scope to match gw1")
+	}
+}
+
+func TestScopesFromClaimsNoScope(t *testing.T) {
+	scopes, err := ScopesFromClaims(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if scopes != nil {
+		t.Errorf("Expected nil scopes for a token without a scope claim, got %v", scopes)
+	}
+}
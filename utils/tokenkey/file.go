@@ -0,0 +1,60 @@
+package tokenkey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// FileProvider loads a static JWKS document from disk. It never refreshes
+// and never hits the network, for air-gapped deployments where the auth
+// server's keys are distributed out of band.
+type FileProvider struct {
+	Path string
+
+	mu   sync.Mutex
+	keys []Key
+}
+
+// NewFileProvider creates a FileProvider that loads its JWKS from path the
+// first time Keys is called.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{Path: path}
+}
+
+// Keys implements Provider. refresh is ignored: the file is only ever read
+// once, since there is nothing to refresh it from.
+func (p *FileProvider) Keys(refresh bool) ([]Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		return p.keys, nil
+	}
+
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to read key file %s: %s", p.Path, err.Error())
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to parse key file %s: %s", p.Path, err.Error())
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		pem, alg, err := k.toPEM()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, Key{Algorithm: alg, KeyID: k.Kid, Key: pem})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("tokenkey: No usable RSA/ECDSA keys found in %s", p.Path)
+	}
+
+	p.keys = keys
+	return keys, nil
+}
@@ -0,0 +1,85 @@
+package tokenkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+func decodeBase64URLBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func marshalPEM(pub interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	block := &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// rsaJWKToPEM converts an RSA JWK ("n", "e") into a PEM-encoded public key.
+func rsaJWKToPEM(k jwk) (string, string, error) {
+	n, err := decodeBase64URLBigInt(k.N)
+	if err != nil {
+		return "", "", fmt.Errorf("tokenkey: Invalid RSA modulus: %s", err.Error())
+	}
+	e, err := decodeBase64URLBigInt(k.E)
+	if err != nil {
+		return "", "", fmt.Errorf("tokenkey: Invalid RSA exponent: %s", err.Error())
+	}
+	pub := &rsa.PublicKey{N: n, E: int(e.Int64())}
+	pemStr, err := marshalPEM(pub)
+	if err != nil {
+		return "", "", err
+	}
+	alg := k.Alg
+	if alg == "" {
+		alg = "RS256"
+	}
+	return pemStr, alg, nil
+}
+
+// ecJWKToPEM converts an EC JWK ("crv", "x", "y") into a PEM-encoded public
+// key.
+func ecJWKToPEM(k jwk) (string, string, error) {
+	var curve elliptic.Curve
+	var alg string
+	switch k.Crv {
+	case "P-256":
+		curve, alg = elliptic.P256(), "ES256"
+	case "P-384":
+		curve, alg = elliptic.P384(), "ES384"
+	case "P-521":
+		curve, alg = elliptic.P521(), "ES512"
+	default:
+		return "", "", fmt.Errorf("tokenkey: Unsupported EC curve %q", k.Crv)
+	}
+	x, err := decodeBase64URLBigInt(k.X)
+	if err != nil {
+		return "", "", fmt.Errorf("tokenkey: Invalid EC x coordinate: %s", err.Error())
+	}
+	y, err := decodeBase64URLBigInt(k.Y)
+	if err != nil {
+		return "", "", fmt.Errorf("tokenkey: Invalid EC y coordinate: %s", err.Error())
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if k.Alg != "" {
+		alg = k.Alg
+	}
+	pemStr, err := marshalPEM(pub)
+	if err != nil {
+		return "", "", err
+	}
+	return pemStr, alg, nil
+}
@@ -0,0 +1,129 @@
+package tokenkey
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// oidcConfiguration is the subset of the OIDC discovery document
+// (/.well-known/openid-configuration) that we care about.
+type oidcConfiguration struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jwk is a single entry of a JSON Web Key Set, covering the RSA and ECDSA
+// fields TTN tokens are signed with.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// ECDSA
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKSProvider discovers its signing keys through standard OIDC/JWKS
+// discovery: it fetches issuer + "/.well-known/openid-configuration",
+// follows "jwks_uri", and keeps the resulting key set cached for cacheTTL.
+type JWKSProvider struct {
+	Issuer string
+
+	mu      sync.Mutex
+	keys    []Key
+	fetched time.Time
+}
+
+// NewJWKSProvider creates a JWKSProvider that discovers its keys from
+// issuer's OIDC configuration document.
+func NewJWKSProvider(issuer string) *JWKSProvider {
+	return &JWKSProvider{Issuer: issuer}
+}
+
+// Keys implements Provider.
+func (p *JWKSProvider) Keys(refresh bool) ([]Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !refresh && p.keys != nil && time.Since(p.fetched) < cacheTTL() {
+		return p.keys, nil
+	}
+
+	keys, err := p.discover()
+	if err != nil {
+		if p.keys != nil {
+			return p.keys, nil
+		}
+		return nil, err
+	}
+
+	p.keys = keys
+	p.fetched = time.Now()
+	return keys, nil
+}
+
+func (p *JWKSProvider) discover() ([]Key, error) {
+	var config oidcConfiguration
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &config); err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to fetch OIDC configuration: %s", err.Error())
+	}
+	if config.JWKSURI == "" {
+		return nil, fmt.Errorf("tokenkey: OIDC configuration at %s has no jwks_uri", p.Issuer)
+	}
+
+	var set jwks
+	if err := getJSON(config.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to fetch JWKS: %s", err.Error())
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		pem, alg, err := k.toPEM()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, Key{Algorithm: alg, KeyID: k.Kid, Key: pem})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("tokenkey: No usable RSA/ECDSA keys found in JWKS at %s", config.JWKSURI)
+	}
+	return keys, nil
+}
+
+// toPEM converts a JWK into PEM-encoded public key material, alongside the
+// JWT "alg" it should be matched against.
+func (k jwk) toPEM() (pem string, alg string, err error) {
+	switch k.Kty {
+	case "RSA":
+		return rsaJWKToPEM(k)
+	case "EC":
+		return ecJWKToPEM(k)
+	default:
+		return "", "", fmt.Errorf("tokenkey: Unsupported key type %q", k.Kty)
+	}
+}
+
+func getJSON(url string, v interface{}) error {
+	res, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
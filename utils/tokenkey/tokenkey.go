@@ -0,0 +1,131 @@
+// Package tokenkey provides the public key(s) TTN components use to verify
+// the signature of OAuth bearer tokens.
+package tokenkey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultCacheTTL is used when "token-key-cache-ttl" is not set.
+const defaultCacheTTL = 30 * time.Second
+
+// Key is a single public key used to verify token signatures.
+type Key struct {
+	// Algorithm is the JWT "alg" this key is used with, e.g. "RS256".
+	Algorithm string
+	// KeyID is the JWT "kid" this key corresponds to. It is empty for
+	// providers that only ever serve a single key.
+	KeyID string
+	// Key is the PEM-encoded public key material.
+	Key string
+}
+
+// Provider provides the public key(s) used to validate bearer tokens.
+type Provider interface {
+	// Keys returns the current candidate keys, forcing a refresh if refresh
+	// is true or the cached set has expired.
+	Keys(refresh bool) ([]Key, error)
+}
+
+// cacheTTL returns the configured token key cache TTL, falling back to
+// defaultCacheTTL if "token-key-cache-ttl" is not set.
+func cacheTTL() time.Duration {
+	if ttl := viper.GetDuration("token-key-cache-ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultCacheTTL
+}
+
+// HTTPProvider fetches a single public key from an HTTP endpoint (the
+// auth server's legacy "/key" endpoint), and caches it to cacheFile so it
+// survives restarts if the auth server is temporarily unreachable.
+type HTTPProvider struct {
+	URL       string
+	cacheFile string
+
+	mu       sync.Mutex
+	key      *Key
+	fetched  time.Time
+}
+
+// NewHTTPProvider creates a new HTTPProvider that fetches its key from url
+// and caches it on disk at cacheFile.
+func NewHTTPProvider(url string, cacheFile string) *HTTPProvider {
+	return &HTTPProvider{URL: url, cacheFile: cacheFile}
+}
+
+// Keys implements Provider.
+func (p *HTTPProvider) Keys(refresh bool) ([]Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !refresh && p.key != nil && time.Since(p.fetched) < cacheTTL() {
+		return []Key{*p.key}, nil
+	}
+
+	key, err := p.fetch()
+	if err != nil {
+		if p.key != nil {
+			return []Key{*p.key}, nil
+		}
+		if cached, cacheErr := p.readCache(); cacheErr == nil {
+			p.key = cached
+			return []Key{*cached}, nil
+		}
+		return nil, err
+	}
+
+	p.key = key
+	p.fetched = time.Now()
+	p.writeCache(key)
+	return []Key{*key}, nil
+}
+
+func (p *HTTPProvider) fetch() (*Key, error) {
+	res, err := http.Get(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to fetch token key: %s", err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokenkey: Failed to fetch token key: server returned %d", res.StatusCode)
+	}
+	key := new(Key)
+	if err := json.NewDecoder(res.Body).Decode(key); err != nil {
+		return nil, fmt.Errorf("tokenkey: Failed to parse token key: %s", err.Error())
+	}
+	return key, nil
+}
+
+func (p *HTTPProvider) readCache() (*Key, error) {
+	if p.cacheFile == "" {
+		return nil, fmt.Errorf("tokenkey: No cache file configured")
+	}
+	data, err := ioutil.ReadFile(p.cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	key := new(Key)
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (p *HTTPProvider) writeCache(key *Key) {
+	if p.cacheFile == "" {
+		return
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return
+	}
+	ioutil.WriteFile(p.cacheFile, data, 0644)
+}